@@ -3,8 +3,11 @@ package handler
 import (
 	"container/list"
 	"errors"
+	"fmt"
 	"math/big"
 	"math/rand"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,14 +30,29 @@ const (
 
 // CfxGasStationHandler handles RPC requests for gas price estimation.
 type CfxGasStationHandler struct {
-	config             *GasStationConfig       // Gas station configuration
-	status             atomic.Value            // Gas station status
-	clientProvider     *node.CfxClientProvider // Client provider to get full node clients
-	clients            []sdk.ClientOperator    // Clients used to get historical data
-	cliIndex           int                     // Index of the main client
-	fromEpoch          uint64                  // Start epoch number to sync from
-	epochBlockHashList *list.List              // Linked list to store epoch block hashes
-	window             *PriorityFeeWindow      // Block priority fee window
+	config             *GasStationConfig        // Gas station configuration
+	status             atomic.Value             // Gas station status
+	clientProvider     *node.CfxClientProvider  // Client provider to get full node clients
+	clients            []sdk.ClientOperator     // Clients used to get historical data
+	cliIndex           int                      // Index of the main client
+	fromEpoch          uint64                   // Start epoch number to sync from
+	epochBlockHashList *list.List               // Linked list to store epoch block hashes
+	window             *PriorityFeeWindow       // Block priority fee window
+	feeHistoryMu       sync.Mutex               // Guards feeHistory
+	feeHistory         *list.List               // Linked list of recent per-block fee history entries
+	archive            *feeArchiveStore         // Optional on-disk archive backing the above, for restart recovery
+	archiveConfig      feeArchiveConfig         // Fee archive configuration (path + retention)
+	lastBaseFee        atomic.Value             // *big.Int, most recently observed block base fee
+	clientHealthMu     sync.Mutex               // Guards clientHealthByURL
+	clientHealthByURL  map[string]*clientHealth // Per-client health/backoff state for parallel fetching
+
+	subsMu           sync.Mutex              // Guards feeSubs and reorgSubs
+	feeSubs          []*feeSubscriber        // Subscribers registered via Subscribe
+	reorgSubs        []*reorgSubscriber      // Subscribers registered via Subscribe
+	feeUpdateConfig  feeUpdateConfig         // Controls how fee updates are coalesced
+	feeUpdateMu      sync.Mutex              // Guards lastEmittedFee/lastEmittedEpoch
+	lastEmittedFee   *types.SuggestedGasFees // Last fee suggestion pushed to feeSubs
+	lastEmittedEpoch uint64                  // Epoch at which lastEmittedFee was pushed
 }
 
 func MustNewCfxGasStationHandlerFromViper(cp *node.CfxClientProvider) *CfxGasStationHandler {
@@ -72,12 +90,91 @@ func MustNewCfxGasStationHandlerFromViper(cp *node.CfxClientProvider) *CfxGasSta
 		epochBlockHashList: list.New(),
 		fromEpoch:          fromEpoch,
 		window:             NewPriorityFeeWindow(cfg.HistoricalPeekCount),
+		feeHistory:         list.New(),
+	}
+
+	viper.MustUnmarshalKey("gasStation.feeUpdate", &h.feeUpdateConfig)
+
+	viper.MustUnmarshalKey("gasStation.feeArchive", &h.archiveConfig)
+	if h.archiveConfig.Enabled {
+		archive, err := openFeeArchiveStore(h.archiveConfig)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to open gas station fee archive")
+		}
+		h.archive = archive
+
+		if err := h.recoverFromArchive(); err != nil {
+			logrus.WithError(err).Fatal("Failed to recover gas station state from fee archive")
+		}
 	}
 
 	go h.run()
 	return h
 }
 
+// recoverFromArchive rebuilds in-memory sync state (the priority fee window,
+// epoch block hash list and fee history) from the on-disk fee archive, so a
+// restart resumes from the last persisted epoch instead of re-syncing
+// HistoricalPeekCount epochs from a fullnode.
+func (h *CfxGasStationHandler) recoverFromArchive() error {
+	lastEpoch, ok, err := h.archive.LastEpoch()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	var fromEpoch uint64
+	if lastEpoch+1 > uint64(h.config.HistoricalPeekCount) {
+		fromEpoch = lastEpoch + 1 - uint64(h.config.HistoricalPeekCount)
+	}
+
+	records, err := h.archive.LoadSince(fromEpoch)
+	if err != nil {
+		return err
+	}
+
+	h.feeHistoryMu.Lock()
+	defer h.feeHistoryMu.Unlock()
+
+	for _, rec := range records {
+		ratio, _ := big.NewInt(0).Div(rec.GasUsed, rec.GasLimit).Float64()
+		blockFee := &BlockPriorityFee{
+			number:       rec.Epoch,
+			hash:         rec.PivotHash,
+			baseFee:      rec.BaseFee,
+			gasUsedRatio: ratio,
+		}
+
+		tips := make([]*TxnPriorityFee, 0, len(rec.TxTips))
+		for i, tip := range rec.TxTips {
+			tips = append(tips, &TxnPriorityFee{hash: fmt.Sprintf("%s-%d", rec.PivotHash, i), tip: tip})
+		}
+		blockFee.Append(tips...)
+		h.window.Push(blockFee)
+
+		h.epochBlockHashList.PushBack([]cfxtypes.Hash{cfxtypes.Hash(rec.PivotHash)})
+		sort.Slice(rec.TxTips, func(i, j int) bool { return rec.TxTips[i].Cmp(rec.TxTips[j]) < 0 })
+		h.feeHistory.PushBack(&feeHistoryEntry{
+			epoch:        rec.Epoch,
+			baseFee:      rec.BaseFee,
+			gasUsedRatio: ratio,
+			tips:         rec.TxTips,
+		})
+
+		h.lastBaseFee.Store(rec.BaseFee)
+	}
+
+	h.fromEpoch = lastEpoch + 1
+	logrus.WithFields(logrus.Fields{
+		"recoveredEpochs": len(records),
+		"fromEpoch":       h.fromEpoch,
+	}).Info("Gas station handler recovered state from fee archive")
+
+	return nil
+}
+
 // run starts to sync historical data and refresh cluster nodes.
 func (h *CfxGasStationHandler) run() {
 	syncTicker := time.NewTimer(0)
@@ -115,6 +212,24 @@ func (h *CfxGasStationHandler) sync() (complete bool, err error) {
 	}
 
 	h.cliIndex %= len(h.clients)
+
+	if len(h.clients) > 1 {
+		latestEpochNo, lerr := h.clients[h.cliIndex].GetEpochNumber(cfxtypes.EpochLatestState)
+		if lerr == nil && latestEpochNo.ToInt().Uint64() > h.fromEpoch+1 {
+			// Sizable backlog with more than one client available: fan the
+			// catch-up out across the group instead of walking one epoch at
+			// a time against a single client.
+			return h.syncParallel(latestEpochNo.ToInt().Uint64())
+		}
+	}
+
+	return h.syncSingle()
+}
+
+// syncSingle synchronizes a single epoch, round-robining across clients on
+// error. This is also the steady-state path once the handler has caught up
+// to the chain tip.
+func (h *CfxGasStationHandler) syncSingle() (complete bool, err error) {
 	for idx := h.cliIndex; ; {
 		complete, err = h.trySync(h.clients[idx])
 		if err != nil {
@@ -185,15 +300,52 @@ func (h *CfxGasStationHandler) trySync(cfx sdk.ClientOperator) (bool, error) {
 		return false, err
 	}
 
+	var epochTips []*TxnPriorityFee
 	for i := range blocks {
-		h.handleBlock(blocks[i])
+		epochTips = append(epochTips, h.handleBlock(h.fromEpoch, blocks[i])...)
 	}
 
+	h.archiveEpoch(pivotBlock, epochTips)
+
 	h.push(blockHashes)
 	h.fromEpoch++
+	h.maybeEmitFeeUpdate()
 	return false, nil
 }
 
+// archiveEpoch persists the epoch just processed to the on-disk fee archive,
+// if one is configured, and prunes segments outside the retention window.
+func (h *CfxGasStationHandler) archiveEpoch(pivotBlock *cfxtypes.Block, epochTips []*TxnPriorityFee) {
+	if h.archive == nil {
+		return
+	}
+
+	tips := make([]*big.Int, len(epochTips))
+	for i, t := range epochTips {
+		tips[i] = t.tip
+	}
+
+	rec := &feeArchiveRecord{
+		Epoch:      h.fromEpoch,
+		PivotHash:  pivotBlock.Hash.String(),
+		ParentHash: pivotBlock.ParentHash.String(),
+		BaseFee:    pivotBlock.BaseFeePerGas.ToInt(),
+		GasUsed:    pivotBlock.GasUsed.ToInt(),
+		GasLimit:   pivotBlock.GasLimit.ToInt(),
+		TxTips:     tips,
+	}
+
+	if err := h.archive.Append(rec); err != nil {
+		logrus.WithError(err).WithField("epoch", h.fromEpoch).Error(
+			"Gas station handler failed to persist epoch to fee archive")
+		return
+	}
+
+	if err := h.archive.Prune(h.fromEpoch, h.archiveConfig.RetentionEpochs); err != nil {
+		logrus.WithError(err).Error("Gas station handler failed to prune fee archive")
+	}
+}
+
 func (h *CfxGasStationHandler) fetchBlocks(
 	cfx sdk.ClientOperator, epoch *cfxtypes.Epoch, pivotBlock *cfxtypes.Block,
 ) ([]cfxtypes.Hash, []*cfxtypes.Block, error) {
@@ -205,7 +357,7 @@ func (h *CfxGasStationHandler) fetchBlocks(
 
 	pivotHash := blockHashes[len(blockHashes)-1]
 	if pivotBlock.Hash != pivotHash { // abandon this epoch due to pivot switched
-		return nil, nil, errors.New("pivot switched")
+		return nil, nil, errPivotSwitched
 	}
 
 	var blocks []*cfxtypes.Block
@@ -228,10 +380,24 @@ func (h *CfxGasStationHandler) handleReorg() {
 	}
 	h.window.Remove(blockHashes...)
 
+	if h.archive != nil {
+		// h.fromEpoch is the epoch about to be (re)synced; the last epoch
+		// actually archived, and the one pop() just removed from the
+		// in-memory window, is h.fromEpoch-1, so truncate from there too.
+		if err := h.archive.TruncateAfter(h.fromEpoch - 1); err != nil {
+			logrus.WithError(err).WithField("epoch", h.fromEpoch-1).Error(
+				"Gas station handler failed to truncate fee archive on reorg")
+		}
+	}
+
+	h.publishReorg(h.fromEpoch-1, blockHashes)
+
 	logrus.WithField("blockHashes", blockHashes).Info("Gas station handler removed blocks due to reorg")
 }
 
-func (h *CfxGasStationHandler) handleBlock(block *cfxtypes.Block) {
+func (h *CfxGasStationHandler) handleBlock(epoch uint64, block *cfxtypes.Block) []*TxnPriorityFee {
+	h.lastBaseFee.Store(block.BaseFeePerGas.ToInt())
+
 	ratio, _ := big.NewInt(0).Div(block.GasUsed.ToInt(), block.GasLimit.ToInt()).Float64()
 	blockFee := &BlockPriorityFee{
 		number:       block.BlockNumber.ToInt().Uint64(),
@@ -282,6 +448,8 @@ func (h *CfxGasStationHandler) handleBlock(block *cfxtypes.Block) {
 
 	blockFee.Append(txnTips...)
 	h.window.Push(blockFee)
+	h.pushFeeHistoryEntry(epoch, block, txnTips)
+	return txnTips
 }
 
 func (h *CfxGasStationHandler) pop() []cfxtypes.Hash {
@@ -355,15 +523,8 @@ func (h *CfxGasStationHandler) Suggest(cfx sdk.ClientOperator) (*types.Suggested
 	stats := h.window.Calculate(h.config.Percentiles[:])
 
 	priorityFees := stats.AvgPercentiledPriorityFee
-	if priorityFees == nil { // use gas fees directly from the blockchain if no estimation made
-		oracleFee, err := cfx.GetMaxPriorityFeePerGas()
-		if err != nil {
-			return nil, err
-		}
-
-		for i := 0; i < 3; i++ {
-			priorityFees = append(priorityFees, oracleFee.ToInt())
-		}
+	if priorityFees == nil { // window is empty, fall back to the fullnode's own oracle
+		priorityFees = h.oracleSuggestPriorityFees(cfx)
 	}
 
 	return &types.SuggestedGasFees{
@@ -387,4 +548,29 @@ func (h *CfxGasStationHandler) Suggest(cfx sdk.ClientOperator) (*types.Suggested
 		PriorityFeeTrend:           stats.PriorityFeeTrend,
 		BaseFeeTrend:               stats.BaseFeeTrend,
 	}, nil
-}
\ No newline at end of file
+}
+
+// CurrentFeeStats returns the most recently observed block base fee and the
+// "Low" percentiled priority fee suggestion computed from the in-memory
+// window, without round-tripping to a fullnode. It's intended for
+// access-control middleware that needs to compare a submitted transaction's
+// tip against live network conditions using the same window that powers
+// Suggest.
+func (h *CfxGasStationHandler) CurrentFeeStats() (baseFee *big.Int, lowPriorityFee *big.Int, err error) {
+	if status := h.status.Load(); status != StationStatusOk {
+		return nil, nil, status.(error)
+	}
+
+	bf, _ := h.lastBaseFee.Load().(*big.Int)
+	if bf == nil {
+		return nil, nil, errors.New("gas station handler has no base fee observation yet")
+	}
+
+	stats := h.window.Calculate(h.config.Percentiles[:])
+	low := big.NewInt(0)
+	if len(stats.AvgPercentiledPriorityFee) > 0 {
+		low = stats.AvgPercentiledPriorityFee[0]
+	}
+
+	return bf, low, nil
+}
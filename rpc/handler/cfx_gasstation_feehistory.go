@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/Conflux-Chain/confura/types"
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/sirupsen/logrus"
+)
+
+// feeHistoryEntry is a per-block snapshot of the fee data needed to answer
+// `cfx_feeHistory` without round-tripping to a fullnode.
+type feeHistoryEntry struct {
+	epoch        uint64
+	baseFee      *big.Int
+	gasUsedRatio float64
+	tips         []*big.Int // ascending priority fees of executed txns in the block
+}
+
+// pushFeeHistoryEntry records a block's fee data for later `FeeHistory` lookups,
+// evicting the oldest entry once the retention limit is reached. Retention is
+// tied to `h.config.HistoricalPeekCount`, the same bound `window` is sized
+// with, so `FeeHistory` and `Suggest` never disagree about how far back
+// history goes.
+func (h *CfxGasStationHandler) pushFeeHistoryEntry(epoch uint64, block *cfxtypes.Block, txnTips []*TxnPriorityFee) {
+	tips := make([]*big.Int, 0, len(txnTips))
+	for _, t := range txnTips {
+		tips = append(tips, t.tip)
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+
+	ratio, _ := big.NewInt(0).Div(block.GasUsed.ToInt(), block.GasLimit.ToInt()).Float64()
+
+	h.feeHistoryMu.Lock()
+	defer h.feeHistoryMu.Unlock()
+
+	if h.feeHistory == nil {
+		h.feeHistory = list.New()
+	}
+
+	h.feeHistory.PushBack(&feeHistoryEntry{
+		epoch:        epoch,
+		baseFee:      block.BaseFeePerGas.ToInt(),
+		gasUsedRatio: ratio,
+		tips:         tips,
+	})
+	if h.feeHistory.Len() > int(h.config.HistoricalPeekCount) {
+		h.feeHistory.Remove(h.feeHistory.Front())
+	}
+}
+
+// FeeHistory returns the base fee, gas used ratio and reward percentiles for the
+// newest `blockCount` recorded blocks at or before `lastEpoch`, computed from the
+// locally retained fee history instead of querying a fullnode. Entries are kept
+// per block, so an epoch with several blocks contributes several entries.
+func (h *CfxGasStationHandler) FeeHistory(
+	blockCount uint64, lastEpoch uint64, rewardPercentiles []float64,
+) (*types.FeeHistory, error) {
+	if status := h.status.Load(); status != StationStatusOk {
+		return nil, status.(error)
+	}
+
+	if err := validateRewardPercentiles(rewardPercentiles); err != nil {
+		return nil, err
+	}
+
+	// Never retain more than HistoricalPeekCount entries, so clamp the
+	// requested count the same way go-ethereum clamps against maxFeeHistory:
+	// an unbounded blockCount from the RPC caller would otherwise size an
+	// allocation straight off attacker-controlled input.
+	if maxBlockCount := uint64(h.config.HistoricalPeekCount); blockCount > maxBlockCount {
+		blockCount = maxBlockCount
+	}
+
+	entries := h.feeHistoryEntriesUpTo(blockCount, lastEpoch)
+	if len(entries) == 0 {
+		return nil, errors.New("no historical fee data available")
+	}
+
+	fh := &types.FeeHistory{
+		OldestEpoch:   hexutil.Uint64(entries[0].epoch),
+		BaseFeePerGas: make([]*hexutil.Big, 0, len(entries)+1),
+		GasUsedRatio:  make([]float64, 0, len(entries)),
+	}
+
+	for _, e := range entries {
+		fh.BaseFeePerGas = append(fh.BaseFeePerGas, (*hexutil.Big)(e.baseFee))
+		fh.GasUsedRatio = append(fh.GasUsedRatio, e.gasUsedRatio)
+
+		if len(rewardPercentiles) == 0 {
+			continue
+		}
+
+		rewards := make([]*hexutil.Big, len(rewardPercentiles))
+		for i, p := range rewardPercentiles {
+			rewards[i] = (*hexutil.Big)(percentiledTip(e.tips, p))
+		}
+		fh.Reward = append(fh.Reward, rewards)
+	}
+
+	// go-ethereum appends one extra (the next block's) base fee; since we can't
+	// project it without the latest block, repeat the last observed base fee.
+	fh.BaseFeePerGas = append(fh.BaseFeePerGas, (*hexutil.Big)(entries[len(entries)-1].baseFee))
+
+	return fh, nil
+}
+
+// feeHistoryEntriesUpTo returns up to `blockCount` of the recorded fee history
+// entries at or before `lastEpoch` (0 meaning the newest available), oldest first.
+func (h *CfxGasStationHandler) feeHistoryEntriesUpTo(blockCount, lastEpoch uint64) []*feeHistoryEntry {
+	h.feeHistoryMu.Lock()
+	defer h.feeHistoryMu.Unlock()
+
+	if h.feeHistory == nil || blockCount == 0 {
+		return nil
+	}
+
+	e := h.feeHistory.Back()
+	for lastEpoch > 0 && e != nil && e.Value.(*feeHistoryEntry).epoch > lastEpoch {
+		e = e.Prev()
+	}
+
+	entries := make([]*feeHistoryEntry, 0, blockCount)
+	for i := uint64(0); i < blockCount && e != nil; i++ {
+		entries = append(entries, e.Value.(*feeHistoryEntry))
+		e = e.Prev()
+	}
+
+	// entries were collected newest-first, reverse to oldest-first.
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries
+}
+
+// validateRewardPercentiles checks that rewardPercentiles are within [0, 100]
+// and monotonically increasing, the same constraint go-ethereum's
+// eth_feeHistory enforces, before they're used to index into a tips slice.
+func validateRewardPercentiles(rewardPercentiles []float64) error {
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("rewardPercentiles: %f out of range [0, 100]", p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return errors.New("rewardPercentiles: not monotonically increasing")
+		}
+	}
+	return nil
+}
+
+// percentiledTip returns the priority fee at the given percentile (0-100) from
+// a slice of ascending-sorted tips, or zero if there are none. percentile must
+// already be validated to lie within [0, 100] (see validateRewardPercentiles).
+func percentiledTip(tips []*big.Int, percentile float64) *big.Int {
+	if len(tips) == 0 {
+		return big.NewInt(0)
+	}
+
+	idx := int(percentile / 100 * float64(len(tips)-1))
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(tips) {
+		idx = len(tips) - 1
+	}
+	return tips[idx]
+}
+
+// oracleSuggestPriorityFees falls back to `cfx_feeHistory` to derive percentiled
+// priority fees when the in-memory window hasn't accumulated any data yet,
+// averaging recent tips at the configured percentiles the same way an EIP-1559
+// fee oracle would. If the fullnode doesn't support `cfx_feeHistory` it falls
+// back further to `cfx_maxPriorityFeePerGas`.
+func (h *CfxGasStationHandler) oracleSuggestPriorityFees(cfx sdk.ClientOperator) []*big.Int {
+	percentiles := h.config.Percentiles[:]
+	history, err := cfx.FeeHistory(hexutil.Uint64(20), cfxtypes.EpochLatestState, percentiles)
+	if err == nil && len(history.Reward) > 0 {
+		return averageFeeHistoryRewards(history.Reward, len(percentiles))
+	}
+
+	logrus.WithError(err).Debug("Gas station handler falling back to cfx_maxPriorityFeePerGas")
+
+	oracleFee, err := cfx.GetMaxPriorityFeePerGas()
+	if err != nil {
+		return make([]*big.Int, len(percentiles))
+	}
+
+	fees := make([]*big.Int, len(percentiles))
+	for i := range fees {
+		fees[i] = oracleFee.ToInt()
+	}
+	return fees
+}
+
+// averageFeeHistoryRewards averages the per-block rewards returned by a
+// `cfx.FeeHistory` call into a single priority fee per requested percentile.
+func averageFeeHistoryRewards(rewards [][]*hexutil.Big, percentileCount int) []*big.Int {
+	avg := make([]*big.Int, percentileCount)
+	for i := range avg {
+		avg[i] = big.NewInt(0)
+	}
+
+	if len(rewards) == 0 {
+		return avg
+	}
+
+	for _, blockRewards := range rewards {
+		for i := 0; i < percentileCount && i < len(blockRewards); i++ {
+			avg[i].Add(avg[i], blockRewards[i].ToInt())
+		}
+	}
+
+	for i := range avg {
+		avg[i].Div(avg[i], big.NewInt(int64(len(rewards))))
+	}
+
+	return avg
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/Conflux-Chain/confura/types"
+	"github.com/openweb3/go-rpc-provider"
+)
+
+// cfxFeeHistoryAPI exposes CfxGasStationHandler.FeeHistory as an RPC service,
+// registered under both the "cfx" and "eth" namespaces so it's dispatched as
+// cfx_feeHistory/eth_feeHistory, matching go-rpc-provider's convention of
+// mapping a service's exported method name to "<namespace>_<methodName>".
+type cfxFeeHistoryAPI struct {
+	gasStation *CfxGasStationHandler
+}
+
+func (api *cfxFeeHistoryAPI) FeeHistory(
+	blockCount uint64, lastEpoch uint64, rewardPercentiles []float64,
+) (*types.FeeHistory, error) {
+	return api.gasStation.FeeHistory(blockCount, lastEpoch, rewardPercentiles)
+}
+
+// APIs returns the rpc.API descriptors the node's RPC server should append to
+// its own API list when the gas station is enabled, registering FeeHistory as
+// the local implementation of cfx_feeHistory/eth_feeHistory.
+func (h *CfxGasStationHandler) APIs() []rpc.API {
+	svc := &cfxFeeHistoryAPI{gasStation: h}
+
+	return []rpc.API{
+		{Namespace: "cfx", Version: "1.0", Service: svc, Public: true},
+		{Namespace: "eth", Version: "1.0", Service: svc, Public: true},
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"container/list"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeHistoryClampsBlockCountToHistoricalPeekCount(t *testing.T) {
+	h := &CfxGasStationHandler{config: &GasStationConfig{HistoricalPeekCount: 5}}
+	h.status.Store(StationStatusOk)
+
+	for epoch := uint64(1); epoch <= 5; epoch++ {
+		h.feeHistory = appendFeeHistoryEntry(h.feeHistory, epoch)
+	}
+
+	// blockCount is far larger than HistoricalPeekCount and, unclamped, would
+	// size an allocation straight off this attacker-controlled input.
+	fh, err := h.FeeHistory(1<<32, 0, nil)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(fh.GasUsedRatio), 5)
+}
+
+func TestFeeHistoryRejectsOutOfRangePercentile(t *testing.T) {
+	h := &CfxGasStationHandler{config: &GasStationConfig{HistoricalPeekCount: 5}}
+	h.status.Store(StationStatusOk)
+	h.feeHistory = appendFeeHistoryEntry(h.feeHistory, 1)
+
+	_, err := h.FeeHistory(1, 0, []float64{200})
+	assert.Error(t, err)
+
+	_, err = h.FeeHistory(1, 0, []float64{-1})
+	assert.Error(t, err)
+}
+
+func TestFeeHistoryRejectsNonMonotonicPercentiles(t *testing.T) {
+	h := &CfxGasStationHandler{config: &GasStationConfig{HistoricalPeekCount: 5}}
+	h.status.Store(StationStatusOk)
+	h.feeHistory = appendFeeHistoryEntry(h.feeHistory, 1)
+
+	_, err := h.FeeHistory(1, 0, []float64{50, 10})
+	assert.Error(t, err)
+}
+
+func TestPercentiledTipDoesNotPanicOutOfRangePercentile(t *testing.T) {
+	tips := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
+
+	assert.NotPanics(t, func() { percentiledTip(tips, 200) })
+	assert.NotPanics(t, func() { percentiledTip(tips, -50) })
+}
+
+func appendFeeHistoryEntry(l *list.List, epoch uint64) *list.List {
+	if l == nil {
+		l = list.New()
+	}
+	l.PushBack(&feeHistoryEntry{
+		epoch:        epoch,
+		baseFee:      big.NewInt(1),
+		gasUsedRatio: 0.5,
+		tips:         []*big.Int{big.NewInt(1)},
+	})
+	return l
+}
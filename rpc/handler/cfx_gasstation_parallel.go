@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	sdk "github.com/Conflux-Chain/go-conflux-sdk"
+	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/Conflux-Chain/go-conflux-util/metrics"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/sirupsen/logrus"
+)
+
+// errPivotSwitched is returned when an epoch's pivot block changed between
+// listing its block hashes and confirming the pivot, shared by the
+// single-epoch and parallel fetch paths.
+var errPivotSwitched = errors.New("pivot switched")
+
+const (
+	// parallelFetchWorkers bounds how many epochs are fetched concurrently
+	// across the client group while catching up.
+	parallelFetchWorkers = 8
+	// parallelFetchBatchEpochs bounds how many epochs a single sync() round
+	// dispatches in parallel, so a reorg doesn't force re-fetching an
+	// unbounded backlog.
+	parallelFetchBatchEpochs = 64
+	// clientQuarantinePeriod is how long a client is skipped after repeated
+	// errors before being given another chance.
+	clientQuarantinePeriod = time.Minute
+	// clientQuarantineThreshold is the number of consecutive errors before a
+	// client is quarantined.
+	clientQuarantineThreshold = 3
+)
+
+var (
+	metricParallelFetchTimer      = metrics.GetOrRegisterTimer("rpc/gasstation/parallelFetch", nil)
+	metricPivotSwitchRetriesMeter = metrics.GetOrRegisterMeter("rpc/gasstation/pivotSwitchRetries", nil)
+)
+
+// clientHealth tracks a client's recent error streak so a slow or forked node
+// can be quarantined instead of repeatedly picked by the parallel fetcher.
+type clientHealth struct {
+	mu              sync.Mutex
+	consecutiveErrs int
+	quarantineUntil time.Time
+}
+
+func (c *clientHealth) recordResult(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		c.consecutiveErrs = 0
+		c.quarantineUntil = time.Time{}
+		return
+	}
+
+	c.consecutiveErrs++
+	if c.consecutiveErrs >= clientQuarantineThreshold {
+		c.quarantineUntil = time.Now().Add(clientQuarantinePeriod)
+	}
+}
+
+func (c *clientHealth) quarantined() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Before(c.quarantineUntil)
+}
+
+// clientErrorCounter returns the Prometheus-backed counter tracking errors
+// for a specific fullnode, lazily registered per node URL.
+func clientErrorCounter(nodeURL string) metrics.Counter {
+	return metrics.GetOrRegisterCounter("rpc/gasstation/clientErrors/"+nodeURL, nil)
+}
+
+// healthOf returns (creating if absent) the clientHealth tracker for a client,
+// keyed by its node URL.
+func (h *CfxGasStationHandler) healthOf(cfx sdk.ClientOperator) *clientHealth {
+	h.clientHealthMu.Lock()
+	defer h.clientHealthMu.Unlock()
+
+	if h.clientHealthByURL == nil {
+		h.clientHealthByURL = make(map[string]*clientHealth)
+	}
+
+	url := cfx.GetNodeURL()
+	ch, ok := h.clientHealthByURL[url]
+	if !ok {
+		ch = &clientHealth{}
+		h.clientHealthByURL[url] = ch
+	}
+	return ch
+}
+
+// healthyClients returns the subset of h.clients that aren't currently
+// quarantined, falling back to the full set if every client is quarantined.
+func (h *CfxGasStationHandler) healthyClients() []sdk.ClientOperator {
+	var healthy []sdk.ClientOperator
+	for _, cfx := range h.clients {
+		if !h.healthOf(cfx).quarantined() {
+			healthy = append(healthy, cfx)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return h.clients
+	}
+	return healthy
+}
+
+// epochFetchResult is the outcome of fetching a single epoch's blocks,
+// produced by a fetchEpochsConcurrently worker.
+type epochFetchResult struct {
+	epoch       uint64
+	pivotBlock  *cfxtypes.Block
+	blockHashes []cfxtypes.Hash
+	blocks      []*cfxtypes.Block
+	err         error
+}
+
+// syncParallel dispatches up to parallelFetchBatchEpochs epochs across the
+// client group with a bounded worker pool, then applies the results strictly
+// in epoch order, aborting (and letting the next tick retry) at the first
+// epoch whose pivot no longer matches the previous one's, e.g. because its
+// pivot switched between the parallel fetch and application. A genuine fetch
+// error (anything but the expected errPivotSwitched) is returned so the
+// caller reports the failure instead of it looking like a clean catch-up.
+func (h *CfxGasStationHandler) syncParallel(latestEpochNo uint64) (complete bool, err error) {
+	start := time.Now()
+	defer func() { metricParallelFetchTimer.Update(time.Since(start)) }()
+
+	batchEnd := h.fromEpoch + parallelFetchBatchEpochs
+	if batchEnd > latestEpochNo+1 {
+		batchEnd = latestEpochNo + 1
+	}
+	epochs := make([]uint64, 0, batchEnd-h.fromEpoch)
+	for e := h.fromEpoch; e < batchEnd; e++ {
+		epochs = append(epochs, e)
+	}
+
+	results := h.fetchEpochsConcurrently(epochs)
+
+	applied := 0
+	var syncErr error
+	for _, res := range results {
+		if res.err != nil {
+			logrus.WithError(res.err).WithField("epoch", res.epoch).Debug(
+				"Gas station handler parallel fetch failed for epoch")
+
+			if errors.Is(res.err, errPivotSwitched) {
+				metricPivotSwitchRetriesMeter.Mark(1)
+			} else {
+				// A genuine fetch failure (e.g. every client down): propagate
+				// it so run() reports the outage via updateStatus/the sync
+				// ticker backoff instead of silently reporting OK.
+				syncErr = res.err
+			}
+			break
+		}
+
+		prevEpochBh := h.prevEpochPivotBlockHash()
+		if len(prevEpochBh) > 0 && prevEpochBh != res.pivotBlock.ParentHash {
+			logrus.WithFields(logrus.Fields{
+				"epoch":          res.epoch,
+				"prevEpochBh":    prevEpochBh,
+				"pivotBlockHash": res.pivotBlock.Hash,
+			}).Debug("Gas station handler detected pivot switch while applying parallel batch")
+			metricPivotSwitchRetriesMeter.Mark(1)
+
+			if applied == 0 {
+				// The very first epoch of the batch already reorged; handle it
+				// the same way the single-epoch path does.
+				h.handleReorg()
+				h.fromEpoch--
+			}
+			break
+		}
+
+		var epochTips []*TxnPriorityFee
+		for i := range res.blocks {
+			epochTips = append(epochTips, h.handleBlock(res.epoch, res.blocks[i])...)
+		}
+		h.archiveEpoch(res.pivotBlock, epochTips)
+		h.push(res.blockHashes)
+		h.fromEpoch++
+		h.maybeEmitFeeUpdate()
+		applied++
+	}
+
+	return h.fromEpoch > latestEpochNo, syncErr
+}
+
+// fetchEpochsConcurrently fetches each epoch in `epochs` using a bounded pool
+// of workers drawn from the healthy client set, returning results in the same
+// order as the input epochs (not completion order).
+func (h *CfxGasStationHandler) fetchEpochsConcurrently(epochs []uint64) []epochFetchResult {
+	results := make([]epochFetchResult, len(epochs))
+
+	clients := h.healthyClients()
+	workers := parallelFetchWorkers
+	if workers > len(epochs) {
+		workers = len(epochs)
+	}
+
+	jobs := make(chan int, len(epochs))
+	for i := range epochs {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			cfx := clients[worker%len(clients)]
+			for idx := range jobs {
+				epoch := epochs[idx]
+				pivotBlock, blockHashes, blocks, err := h.fetchEpoch(cfx, epoch)
+
+				// errPivotSwitched is an expected signal during ordinary chain
+				// reorg activity, not a sign of a faulty client; don't let it
+				// count toward quarantine or error metrics.
+				if !errors.Is(err, errPivotSwitched) {
+					h.healthOf(cfx).recordResult(err)
+					if err != nil {
+						clientErrorCounter(cfx.GetNodeURL()).Inc(1)
+					}
+				}
+
+				results[idx] = epochFetchResult{
+					epoch:       epoch,
+					pivotBlock:  pivotBlock,
+					blockHashes: blockHashes,
+					blocks:      blocks,
+					err:         err,
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// fetchEpoch fetches a single epoch's pivot block and full block set from
+// cfx, reusing the same pivot-consistency check as fetchBlocks.
+func (h *CfxGasStationHandler) fetchEpoch(
+	cfx sdk.ClientOperator, epochNo uint64,
+) (pivotBlock *cfxtypes.Block, blockHashes []cfxtypes.Hash, blocks []*cfxtypes.Block, err error) {
+	epoch := cfxtypes.NewEpochNumberUint64(epochNo)
+
+	pivotBlock, err = cfx.GetBlockByEpoch(epoch)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	blockHashes, blocks, err = h.fetchBlocksAtEpoch(cfx, epochNo, epoch, pivotBlock)
+	return pivotBlock, blockHashes, blocks, err
+}
+
+// fetchBlocksAtEpoch is fetchBlocks generalized to an explicit epoch number,
+// since parallel workers fetch epochs other than h.fromEpoch.
+func (h *CfxGasStationHandler) fetchBlocksAtEpoch(
+	cfx sdk.ClientOperator, epochNo uint64, epoch *cfxtypes.Epoch, pivotBlock *cfxtypes.Block,
+) ([]cfxtypes.Hash, []*cfxtypes.Block, error) {
+	blockHashes, err := cfx.GetBlocksByEpoch(epoch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pivotHash := blockHashes[len(blockHashes)-1]
+	if pivotBlock.Hash != pivotHash {
+		return nil, nil, errPivotSwitched
+	}
+
+	var blocks []*cfxtypes.Block
+	for i := 0; i < len(blockHashes)-1; i++ {
+		block, err := cfx.GetBlockByHashWithPivotAssumption(blockHashes[i], pivotHash, hexutil.Uint64(epochNo))
+		if err != nil {
+			return nil, nil, err
+		}
+		blocks = append(blocks, &block)
+	}
+
+	blocks = append(blocks, pivotBlock)
+	return blockHashes, blocks, nil
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"container/list"
+	"math/big"
+	"testing"
+
+	cfxtypes "github.com/Conflux-Chain/go-conflux-sdk/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandleReorgTruncatesArchiveThroughCallSite exercises handleReorg the
+// same way trySync/syncParallel drive it: handleReorg() is called first,
+// then h.fromEpoch is decremented by the caller. It checks that the stale
+// epoch popped off the in-memory window is also gone from the on-disk
+// archive, so a subsequent resync doesn't leave a duplicate record behind.
+func TestHandleReorgTruncatesArchiveThroughCallSite(t *testing.T) {
+	archive, err := openFeeArchiveStore(feeArchiveConfig{Path: t.TempDir(), SegmentEpochs: 10})
+	if err != nil {
+		t.Fatalf("openFeeArchiveStore: %v", err)
+	}
+	defer archive.Close()
+
+	h := &CfxGasStationHandler{
+		config:             &GasStationConfig{HistoricalPeekCount: 10},
+		epochBlockHashList: list.New(),
+		window:             NewPriorityFeeWindow(10),
+		archive:            archive,
+		fromEpoch:          101,
+	}
+
+	// Epoch 100 was the last epoch successfully synced and archived.
+	staleHash := cfxtypes.Hash("0xstale")
+	h.push([]cfxtypes.Hash{staleHash})
+	if err := h.archive.Append(&feeArchiveRecord{
+		Epoch:     100,
+		PivotHash: staleHash.String(),
+		BaseFee:   big.NewInt(1),
+		GasUsed:   big.NewInt(1),
+		GasLimit:  big.NewInt(1),
+	}); err != nil {
+		t.Fatalf("Append stale record: %v", err)
+	}
+
+	// Reorg detected while trying to sync epoch 101: the pivot's parent hash
+	// no longer matches epoch 100's pivot, so epoch 100 is rolled back.
+	h.handleReorg()
+	h.fromEpoch--
+
+	// Resync re-archives epoch 100 with the new, post-reorg pivot.
+	freshHash := cfxtypes.Hash("0xfresh")
+	if err := h.archive.Append(&feeArchiveRecord{
+		Epoch:     100,
+		PivotHash: freshHash.String(),
+		BaseFee:   big.NewInt(2),
+		GasUsed:   big.NewInt(2),
+		GasLimit:  big.NewInt(2),
+	}); err != nil {
+		t.Fatalf("Append fresh record: %v", err)
+	}
+
+	records, err := h.archive.LoadSince(100)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1, "reorg must truncate the stale epoch, not leave a duplicate")
+	assert.Equal(t, freshHash.String(), records[0].PivotHash)
+}
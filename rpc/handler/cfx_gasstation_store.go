@@ -0,0 +1,620 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// feeArchiveConfig configures the on-disk, era1-style archive that persists
+// the gas station's historical block fee data across restarts, so the handler
+// doesn't have to re-sync `HistoricalPeekCount` epochs from a fullnode on
+// every restart.
+type feeArchiveConfig struct {
+	// Enabled turns on disk persistence of the priority fee window.
+	Enabled bool
+	// Path is the directory where fee archive segments are stored.
+	Path string
+	// SegmentEpochs is the number of epoch records grouped into one segment file.
+	SegmentEpochs uint64
+	// RetentionEpochs is the max number of epochs to retain on disk; segments
+	// entirely older than this are pruned. 0 means keep everything.
+	RetentionEpochs uint64
+}
+
+// feeArchiveRecord is the unit persisted for a single epoch, mirroring the
+// data `CfxGasStationHandler` keeps in memory (`epochBlockHashList` + the
+// per-block entries fed into `window`).
+type feeArchiveRecord struct {
+	Epoch      uint64
+	PivotHash  string
+	ParentHash string
+	BaseFee    *big.Int
+	GasUsed    *big.Int
+	GasLimit   *big.Int
+	TxTips     []*big.Int
+}
+
+// feeArchiveStore is an append-only, segmented archive of feeArchiveRecords,
+// loosely modeled after era1 archives: fixed segments of epoch records with an
+// (epoch -> offset) index and a checksum per record so corruption or
+// a torn write can be detected on load instead of silently feeding a bad
+// window. The index is kept in a sidecar "<start>.era1.idx" file per segment,
+// not appended to the end of the segment's data file.
+type feeArchiveStore struct {
+	mu sync.Mutex
+
+	dir           string
+	segmentEpochs uint64
+
+	segStartEpochs []uint64 // sorted start epoch of every segment on disk
+	curFile        *os.File
+	curIndex       *os.File
+	curStart       uint64
+}
+
+// segmentPaths returns the data and index file paths for the segment starting
+// at `startEpoch`.
+func (s *feeArchiveStore) segmentPaths(startEpoch uint64) (data, index string) {
+	base := fmt.Sprintf("%020d.era1", startEpoch)
+	return filepath.Join(s.dir, base), filepath.Join(s.dir, base+".idx")
+}
+
+// openFeeArchiveStore opens (creating if absent) the archive directory,
+// discovers existing segments and rebuilds the in-memory segment index.
+func openFeeArchiveStore(cfg feeArchiveConfig) (*feeArchiveStore, error) {
+	if cfg.SegmentEpochs == 0 {
+		cfg.SegmentEpochs = 1000
+	}
+
+	if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+		return nil, fmt.Errorf("create fee archive dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read fee archive dir: %w", err)
+	}
+
+	s := &feeArchiveStore{dir: cfg.Path, segmentEpochs: cfg.SegmentEpochs}
+	for _, e := range entries {
+		// Segment data files are named "<start>.era1"; their sidecar index
+		// files are "<start>.era1.idx" and must be skipped here, or else
+		// "%020d.era1" also matches the idx file (Sscanf doesn't require
+		// the format to consume the whole string) and every segment gets
+		// double-counted.
+		if !strings.HasSuffix(e.Name(), ".era1") {
+			continue
+		}
+
+		var start uint64
+		if _, err := fmt.Sscanf(e.Name(), "%020d.era1", &start); err == nil {
+			s.segStartEpochs = append(s.segStartEpochs, start)
+		}
+	}
+	sort.Slice(s.segStartEpochs, func(i, j int) bool { return s.segStartEpochs[i] < s.segStartEpochs[j] })
+
+	if len(s.segStartEpochs) > 0 {
+		if err := s.openCurrentSegment(s.segStartEpochs[len(s.segStartEpochs)-1]); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *feeArchiveStore) openCurrentSegment(start uint64) error {
+	dataPath, idxPath := s.segmentPaths(start)
+
+	data, err := os.OpenFile(dataPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open segment data file: %w", err)
+	}
+
+	idx, err := os.OpenFile(idxPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		data.Close()
+		return fmt.Errorf("open segment index file: %w", err)
+	}
+
+	s.curFile, s.curIndex, s.curStart = data, idx, start
+	return nil
+}
+
+// Close closes the currently open segment, if any.
+func (s *feeArchiveStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.curFile == nil {
+		return nil
+	}
+
+	err := s.curFile.Close()
+	if ierr := s.curIndex.Close(); err == nil {
+		err = ierr
+	}
+	return err
+}
+
+// LastEpoch returns the last persisted epoch, rebuilding recovery state by
+// validating the trailing records of the newest segment and truncating any
+// torn write left by a crash mid-append.
+func (s *feeArchiveStore) LastEpoch() (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.curFile == nil {
+		return 0, false, nil
+	}
+
+	last, _, err := s.repairAndFindLast(s.curFile)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if last == nil {
+		return 0, false, nil
+	}
+	return last.Epoch, true, nil
+}
+
+// repairAndFindLast streams the records in `f`, verifying each record's
+// checksum, and truncates the file at the first corrupt/incomplete record it
+// finds (the tell-tale sign of a crash mid-write). It returns the last valid
+// record, if any.
+func (s *feeArchiveStore) repairAndFindLast(f *os.File) (last *feeArchiveRecord, offset int64, err error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	r := bufio.NewReader(f)
+	var pos int64
+	for {
+		rec, n, rerr := decodeFeeArchiveRecord(r)
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			logrus.WithError(rerr).WithField("offset", pos).Warn(
+				"Gas station fee archive found a corrupt/torn record, truncating")
+			if terr := f.Truncate(pos); terr != nil {
+				return nil, 0, terr
+			}
+			break
+		}
+
+		last = rec
+		offset = pos
+		pos += int64(n)
+	}
+
+	return last, offset, nil
+}
+
+// LoadSince streams every record with epoch >= fromEpoch across all segments,
+// in ascending epoch order, for rebuilding the in-memory window on startup.
+func (s *feeArchiveStore) LoadSince(fromEpoch uint64) ([]*feeArchiveRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []*feeArchiveRecord
+	for _, start := range s.segStartEpochs {
+		if len(s.segStartEpochs) > 0 && start < s.curStart && start+s.segmentEpochs <= fromEpoch {
+			continue // whole segment is older than what's requested
+		}
+
+		dataPath, _ := s.segmentPaths(start)
+		f, err := os.Open(dataPath)
+		if err != nil {
+			return nil, fmt.Errorf("open segment %d: %w", start, err)
+		}
+
+		recs, _, err := s.streamValidRecords(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rec := range recs {
+			if rec.Epoch >= fromEpoch {
+				records = append(records, rec)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+func (s *feeArchiveStore) streamValidRecords(f *os.File) ([]*feeArchiveRecord, int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	r := bufio.NewReader(f)
+	var records []*feeArchiveRecord
+	var pos int64
+	for {
+		rec, n, err := decodeFeeArchiveRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Corruption past the live tail is unexpected outside of crash
+			// recovery of the current segment; stop reading rather than
+			// returning partially-decoded data.
+			logrus.WithError(err).WithField("offset", pos).Error(
+				"Gas station fee archive segment corrupted")
+			break
+		}
+
+		records = append(records, rec)
+		pos += int64(n)
+	}
+
+	return records, pos, nil
+}
+
+// Append persists `rec`, rolling over to a new segment every `segmentEpochs`
+// epochs, and records its offset in the segment's sidecar index file.
+func (s *feeArchiveStore) Append(rec *feeArchiveRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.curFile == nil || rec.Epoch >= s.curStart+s.segmentEpochs {
+		start := rec.Epoch - (rec.Epoch % s.segmentEpochs)
+		if s.curFile != nil {
+			s.curFile.Close()
+			s.curIndex.Close()
+		}
+		if err := s.openCurrentSegment(start); err != nil {
+			return err
+		}
+		s.segStartEpochs = append(s.segStartEpochs, start)
+	}
+
+	offset, err := s.curFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.curFile.Write(encodeFeeArchiveRecord(rec)); err != nil {
+		return err
+	}
+	if err := s.curFile.Sync(); err != nil {
+		return err
+	}
+
+	return s.appendIndexEntry(rec.Epoch, offset)
+}
+
+func (s *feeArchiveStore) appendIndexEntry(epoch uint64, offset int64) error {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[0:8], epoch)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(offset))
+
+	if _, err := s.curIndex.Write(buf[:]); err != nil {
+		return err
+	}
+	return s.curIndex.Sync()
+}
+
+// TruncateAfter drops every persisted record with epoch >= fromEpoch, used to
+// roll the archive back when `handleReorg` discards in-memory epochs. A
+// rollback point isn't guaranteed to fall in the current (newest) segment:
+// repeated single-epoch reorgs can walk fromEpoch back across a segment
+// boundary, so every segment that might still hold epoch >= fromEpoch is
+// rewritten (or removed if nothing survives), keeping each segment's
+// filename in sync with the epoch range it actually contains.
+func (s *feeArchiveStore) TruncateAfter(fromEpoch uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.curFile == nil {
+		return nil
+	}
+
+	segStartEpochs := append([]uint64(nil), s.segStartEpochs...)
+
+	var kept []uint64
+	for _, start := range segStartEpochs {
+		if start+s.segmentEpochs <= fromEpoch {
+			kept = append(kept, start) // entirely before the rollback point
+			continue
+		}
+
+		hasRecords, err := s.truncateSegment(start, fromEpoch)
+		if err != nil {
+			return err
+		}
+		if hasRecords {
+			kept = append(kept, start)
+		} else if err := s.removeSegment(start); err != nil {
+			return err
+		}
+	}
+
+	s.segStartEpochs = kept
+
+	// The segment that used to be current may have been rewritten or
+	// removed above; re-derive it from what's left.
+	s.curFile.Close()
+	s.curIndex.Close()
+	s.curFile, s.curIndex = nil, nil
+
+	if len(s.segStartEpochs) == 0 {
+		return nil
+	}
+
+	sort.Slice(s.segStartEpochs, func(i, j int) bool { return s.segStartEpochs[i] < s.segStartEpochs[j] })
+	return s.openCurrentSegment(s.segStartEpochs[len(s.segStartEpochs)-1])
+}
+
+// truncateSegment rewrites the segment starting at `start`, keeping only
+// records with epoch < fromEpoch, and reports whether any record survived.
+func (s *feeArchiveStore) truncateSegment(start, fromEpoch uint64) (bool, error) {
+	dataPath, idxPath := s.segmentPaths(start)
+
+	f, err := os.OpenFile(dataPath, os.O_RDWR, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("open segment %d for truncation: %w", start, err)
+	}
+	defer f.Close()
+
+	records, _, err := s.streamValidRecords(f)
+	if err != nil {
+		return false, err
+	}
+
+	idx, err := os.OpenFile(idxPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("open segment %d index for truncation: %w", start, err)
+	}
+	defer idx.Close()
+
+	if err := f.Truncate(0); err != nil {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	if err := idx.Truncate(0); err != nil {
+		return false, err
+	}
+	if _, err := idx.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	var kept int
+	for _, rec := range records {
+		if rec.Epoch >= fromEpoch {
+			continue
+		}
+
+		offset, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return false, err
+		}
+		if _, err := f.Write(encodeFeeArchiveRecord(rec)); err != nil {
+			return false, err
+		}
+
+		var buf [16]byte
+		binary.BigEndian.PutUint64(buf[0:8], rec.Epoch)
+		binary.BigEndian.PutUint64(buf[8:16], uint64(offset))
+		if _, err := idx.Write(buf[:]); err != nil {
+			return false, err
+		}
+		kept++
+	}
+
+	if err := f.Sync(); err != nil {
+		return false, err
+	}
+	if err := idx.Sync(); err != nil {
+		return false, err
+	}
+
+	return kept > 0, nil
+}
+
+// removeSegment deletes a segment's data and index files entirely, used by
+// TruncateAfter when every record in it was rolled back.
+func (s *feeArchiveStore) removeSegment(start uint64) error {
+	dataPath, idxPath := s.segmentPaths(start)
+	if err := os.Remove(dataPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(idxPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Prune removes whole segments older than `retentionEpochs` relative to
+// `latestEpoch`.
+func (s *feeArchiveStore) Prune(latestEpoch, retentionEpochs uint64) error {
+	if retentionEpochs == 0 || latestEpoch <= retentionEpochs {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := latestEpoch - retentionEpochs
+	var kept []uint64
+	for _, start := range s.segStartEpochs {
+		if start == s.curStart || start+s.segmentEpochs > cutoff {
+			kept = append(kept, start)
+			continue
+		}
+
+		dataPath, idxPath := s.segmentPaths(start)
+		if err := os.Remove(dataPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(idxPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	s.segStartEpochs = kept
+	return nil
+}
+
+// encodeFeeArchiveRecord serializes rec as:
+//
+//	[4 bytes length][payload][4 bytes crc32 checksum of payload]
+//
+// so a torn write (crash mid-append) is detectable as either a short read or
+// a checksum mismatch on the next load.
+func encodeFeeArchiveRecord(rec *feeArchiveRecord) []byte {
+	payload := marshalFeeArchiveRecord(rec)
+
+	buf := make([]byte, 4+len(payload)+4)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	copy(buf[4:], payload)
+	binary.BigEndian.PutUint32(buf[4+len(payload):], crc32.ChecksumIEEE(payload))
+	return buf
+}
+
+// decodeFeeArchiveRecord reads one record from r, returning the number of
+// bytes consumed (for offset bookkeeping) and io.EOF only at a clean
+// record boundary.
+func decodeFeeArchiveRecord(r *bufio.Reader) (*feeArchiveRecord, int, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, 0, fmt.Errorf("truncated record length")
+		}
+		return nil, 0, err
+	}
+	payloadLen := binary.BigEndian.Uint32(lenBuf[:])
+
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, fmt.Errorf("truncated record payload: %w", err)
+	}
+
+	var crcBuf [4]byte
+	if _, err := io.ReadFull(r, crcBuf[:]); err != nil {
+		return nil, 0, fmt.Errorf("truncated record checksum: %w", err)
+	}
+
+	if want, got := binary.BigEndian.Uint32(crcBuf[:]), crc32.ChecksumIEEE(payload); want != got {
+		return nil, 0, fmt.Errorf("record checksum mismatch: want %x got %x", want, got)
+	}
+
+	rec, err := unmarshalFeeArchiveRecord(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rec, 4 + len(payload) + 4, nil
+}
+
+// marshalFeeArchiveRecord/unmarshalFeeArchiveRecord use a flat, fixed-field
+// binary layout (big.Int values as length-prefixed big-endian bytes) rather
+// than a generic encoder, keeping the on-disk format stable and cheap to
+// stream.
+func marshalFeeArchiveRecord(rec *feeArchiveRecord) []byte {
+	var buf []byte
+
+	var hdr [8]byte
+	binary.BigEndian.PutUint64(hdr[:], rec.Epoch)
+	buf = append(buf, hdr[:]...)
+
+	buf = appendLenPrefixed(buf, []byte(rec.PivotHash))
+	buf = appendLenPrefixed(buf, []byte(rec.ParentHash))
+	buf = appendLenPrefixed(buf, bigIntBytes(rec.BaseFee))
+	buf = appendLenPrefixed(buf, bigIntBytes(rec.GasUsed))
+	buf = appendLenPrefixed(buf, bigIntBytes(rec.GasLimit))
+
+	var tipCount [4]byte
+	binary.BigEndian.PutUint32(tipCount[:], uint32(len(rec.TxTips)))
+	buf = append(buf, tipCount[:]...)
+	for _, tip := range rec.TxTips {
+		buf = appendLenPrefixed(buf, bigIntBytes(tip))
+	}
+
+	return buf
+}
+
+func unmarshalFeeArchiveRecord(b []byte) (*feeArchiveRecord, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("record too short")
+	}
+
+	rec := &feeArchiveRecord{Epoch: binary.BigEndian.Uint64(b[:8])}
+	rest := b[8:]
+
+	var pivotHash, parentHash, baseFee, gasUsed, gasLimit []byte
+	var err error
+	for _, dst := range []*[]byte{&pivotHash, &parentHash, &baseFee, &gasUsed, &gasLimit} {
+		*dst, rest, err = readLenPrefixed(rest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rest) < 4 {
+		return nil, fmt.Errorf("record missing tip count")
+	}
+	tipCount := binary.BigEndian.Uint32(rest[:4])
+	rest = rest[4:]
+
+	tips := make([]*big.Int, 0, tipCount)
+	for i := uint32(0); i < tipCount; i++ {
+		var tipBytes []byte
+		tipBytes, rest, err = readLenPrefixed(rest)
+		if err != nil {
+			return nil, err
+		}
+		tips = append(tips, new(big.Int).SetBytes(tipBytes))
+	}
+
+	rec.PivotHash = string(pivotHash)
+	rec.ParentHash = string(parentHash)
+	rec.BaseFee = new(big.Int).SetBytes(baseFee)
+	rec.GasUsed = new(big.Int).SetBytes(gasUsed)
+	rec.GasLimit = new(big.Int).SetBytes(gasLimit)
+	rec.TxTips = tips
+
+	return rec, nil
+}
+
+func appendLenPrefixed(buf, data []byte) []byte {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	buf = append(buf, n[:]...)
+	return append(buf, data...)
+}
+
+func readLenPrefixed(b []byte) (data, rest []byte, err error) {
+	if len(b) < 4 {
+		return nil, nil, fmt.Errorf("truncated length prefix")
+	}
+	n := binary.BigEndian.Uint32(b[:4])
+	b = b[4:]
+	if uint32(len(b)) < n {
+		return nil, nil, fmt.Errorf("truncated field")
+	}
+	return b[:n], b[n:], nil
+}
+
+func bigIntBytes(v *big.Int) []byte {
+	if v == nil {
+		return nil
+	}
+	return v.Bytes()
+}
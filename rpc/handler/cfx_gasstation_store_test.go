@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestFeeArchiveRecord(epoch uint64) *feeArchiveRecord {
+	return &feeArchiveRecord{
+		Epoch:      epoch,
+		PivotHash:  "0xpivot",
+		ParentHash: "0xparent",
+		BaseFee:    big.NewInt(int64(epoch) + 1),
+		GasUsed:    big.NewInt(50),
+		GasLimit:   big.NewInt(100),
+		TxTips:     []*big.Int{big.NewInt(1), big.NewInt(2)},
+	}
+}
+
+func TestFeeArchiveStoreSegmentDiscoveryIgnoresIndexFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := openFeeArchiveStore(feeArchiveConfig{Path: dir, SegmentEpochs: 10})
+	if err != nil {
+		t.Fatalf("openFeeArchiveStore: %v", err)
+	}
+
+	for epoch := uint64(0); epoch < 15; epoch++ {
+		if err := s.Append(newTestFeeArchiveRecord(epoch)); err != nil {
+			t.Fatalf("Append(%d): %v", epoch, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Reopening must discover each segment's data file exactly once, not
+	// once per data file plus once per its ".era1.idx" sidecar.
+	reopened, err := openFeeArchiveStore(feeArchiveConfig{Path: dir, SegmentEpochs: 10})
+	if err != nil {
+		t.Fatalf("re-open: %v", err)
+	}
+	defer reopened.Close()
+
+	if got, want := len(reopened.segStartEpochs), 2; got != want {
+		t.Fatalf("segStartEpochs count = %d, want %d (segments: %v)", got, want, reopened.segStartEpochs)
+	}
+
+	records, err := reopened.LoadSince(0)
+	if err != nil {
+		t.Fatalf("LoadSince: %v", err)
+	}
+	if got, want := len(records), 15; got != want {
+		t.Fatalf("LoadSince returned %d records, want %d (segment discovery double-counted a segment)", got, want)
+	}
+}
+
+func TestFeeArchiveStoreCrashRecoveryTruncatesTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := openFeeArchiveStore(feeArchiveConfig{Path: dir, SegmentEpochs: 100})
+	if err != nil {
+		t.Fatalf("openFeeArchiveStore: %v", err)
+	}
+
+	for epoch := uint64(0); epoch < 5; epoch++ {
+		if err := s.Append(newTestFeeArchiveRecord(epoch)); err != nil {
+			t.Fatalf("Append(%d): %v", epoch, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Simulate a crash mid-write: append a partial record (only its length
+	// prefix and a few payload bytes, no checksum) after the last valid one.
+	dataPath := filepath.Join(dir, "00000000000000000000.era1")
+	f, err := os.OpenFile(dataPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open data file: %v", err)
+	}
+	if _, err := f.Write([]byte{0x00, 0x00, 0x10, 0x00, 0xde, 0xad}); err != nil {
+		t.Fatalf("write torn record: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close data file: %v", err)
+	}
+
+	reopened, err := openFeeArchiveStore(feeArchiveConfig{Path: dir, SegmentEpochs: 100})
+	if err != nil {
+		t.Fatalf("re-open: %v", err)
+	}
+	defer reopened.Close()
+
+	lastEpoch, ok, err := reopened.LastEpoch()
+	if err != nil {
+		t.Fatalf("LastEpoch: %v", err)
+	}
+	if !ok {
+		t.Fatalf("LastEpoch: ok = false, want true")
+	}
+	if got, want := lastEpoch, uint64(4); got != want {
+		t.Fatalf("LastEpoch = %d, want %d (torn record should have been truncated)", got, want)
+	}
+
+	// The archive should still accept new appends after the torn tail was
+	// repaired, continuing right after the last valid epoch.
+	if err := reopened.Append(newTestFeeArchiveRecord(5)); err != nil {
+		t.Fatalf("Append after recovery: %v", err)
+	}
+	records, err := reopened.LoadSince(0)
+	if err != nil {
+		t.Fatalf("LoadSince: %v", err)
+	}
+	if got, want := len(records), 6; got != want {
+		t.Fatalf("LoadSince returned %d records, want %d", got, want)
+	}
+}
+
+func TestFeeArchiveStoreTruncateAfterAcrossSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := openFeeArchiveStore(feeArchiveConfig{Path: dir, SegmentEpochs: 5})
+	if err != nil {
+		t.Fatalf("openFeeArchiveStore: %v", err)
+	}
+	defer s.Close()
+
+	// Three segments: [0-4], [5-9], [10-14].
+	for epoch := uint64(0); epoch < 15; epoch++ {
+		if err := s.Append(newTestFeeArchiveRecord(epoch)); err != nil {
+			t.Fatalf("Append(%d): %v", epoch, err)
+		}
+	}
+
+	// Roll back into the oldest (already-rolled-over) segment, as repeated
+	// single-epoch reorgs walking fromEpoch back across segment boundaries
+	// would.
+	if err := s.TruncateAfter(3); err != nil {
+		t.Fatalf("TruncateAfter: %v", err)
+	}
+
+	records, err := s.LoadSince(0)
+	if err != nil {
+		t.Fatalf("LoadSince: %v", err)
+	}
+	if got, want := len(records), 3; got != want {
+		t.Fatalf("LoadSince returned %d records, want %d (stale records survived truncation)", got, want)
+	}
+	for _, rec := range records {
+		if rec.Epoch >= 3 {
+			t.Fatalf("found record for epoch %d, should have been truncated", rec.Epoch)
+		}
+	}
+
+	// The newer, now-empty segments must be gone entirely, not left behind
+	// with a filename that no longer matches their (empty) contents.
+	if got, want := len(s.segStartEpochs), 1; got != want {
+		t.Fatalf("segStartEpochs = %v, want exactly 1 surviving segment", s.segStartEpochs)
+	}
+
+	// Further appends must go through the current segment pointer without
+	// corrupting the epoch-range-to-filename invariant Prune/LoadSince rely on.
+	if err := s.Append(newTestFeeArchiveRecord(3)); err != nil {
+		t.Fatalf("Append after truncation: %v", err)
+	}
+	lastEpoch, ok, err := s.LastEpoch()
+	if err != nil {
+		t.Fatalf("LastEpoch: %v", err)
+	}
+	if !ok || lastEpoch != 3 {
+		t.Fatalf("LastEpoch = (%d, %v), want (3, true)", lastEpoch, ok)
+	}
+}
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/Conflux-Chain/confura/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// subscribeChanBuffer bounds how many pending updates a subscriber channel
+// can hold before new ones are dropped for it, so a slow subscriber can't
+// stall epoch processing.
+const subscribeChanBuffer = 16
+
+// ReorgEvent describes the epoch and block hashes discarded by handleReorg,
+// so downstream indexers subscribed via Subscribe can invalidate their caches.
+type ReorgEvent struct {
+	Epoch       uint64
+	BlockHashes []string
+}
+
+// feeUpdateConfig controls how aggressively Subscribe's fee updates are
+// coalesced before being pushed to subscribers.
+type feeUpdateConfig struct {
+	// EpsilonWei is the priority fee change, in wei, that any of
+	// Low/Medium/High must exceed to trigger a push on its own.
+	EpsilonWei int64
+	// EveryEpochs forces a push at least once every N processed epochs, even
+	// without a meaningful change. 0 disables the periodic push.
+	EveryEpochs uint64
+}
+
+type feeSubscriber struct {
+	ch chan *types.SuggestedGasFees
+}
+
+type reorgSubscriber struct {
+	ch chan ReorgEvent
+}
+
+// Subscribe registers the caller for gas-fee suggestion and reorg event
+// updates, meant to back a `cfx_subscribe("gasPrice", ...)` /
+// `eth_subscribe("gasPrice", ...)` topic so dApps and wallets can stream
+// Low/Medium/High updates instead of polling Suggest. Both channels are
+// closed once ctx is done.
+//
+// Fee updates are coalesced: pushed only on a change bigger than the
+// configured epsilon, or every EveryEpochs processed epochs, so subscribers
+// aren't flooded on every block.
+func (h *CfxGasStationHandler) Subscribe(ctx context.Context) (<-chan *types.SuggestedGasFees, <-chan ReorgEvent, error) {
+	if status := h.status.Load(); status != StationStatusOk {
+		return nil, nil, status.(error)
+	}
+
+	feeCh := make(chan *types.SuggestedGasFees, subscribeChanBuffer)
+	reorgCh := make(chan ReorgEvent, subscribeChanBuffer)
+
+	h.subsMu.Lock()
+	h.feeSubs = append(h.feeSubs, &feeSubscriber{ch: feeCh})
+	h.reorgSubs = append(h.reorgSubs, &reorgSubscriber{ch: reorgCh})
+	h.subsMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.unsubscribe(feeCh, reorgCh)
+	}()
+
+	return feeCh, reorgCh, nil
+}
+
+// unsubscribe removes and closes the subscriber channels registered by a
+// prior Subscribe call.
+func (h *CfxGasStationHandler) unsubscribe(feeCh chan *types.SuggestedGasFees, reorgCh chan ReorgEvent) {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+
+	for i, sub := range h.feeSubs {
+		if sub.ch == feeCh {
+			h.feeSubs = append(h.feeSubs[:i], h.feeSubs[i+1:]...)
+			close(feeCh)
+			break
+		}
+	}
+
+	for i, sub := range h.reorgSubs {
+		if sub.ch == reorgCh {
+			h.reorgSubs = append(h.reorgSubs[:i], h.reorgSubs[i+1:]...)
+			close(reorgCh)
+			break
+		}
+	}
+}
+
+// publishReorg notifies subscribers that blockHashes were discarded at
+// epoch, dropping the event for any subscriber whose channel is full rather
+// than blocking epoch processing.
+func (h *CfxGasStationHandler) publishReorg(epoch uint64, blockHashes []string) {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+
+	if len(h.reorgSubs) == 0 {
+		return
+	}
+
+	evt := ReorgEvent{Epoch: epoch, BlockHashes: blockHashes}
+	for _, sub := range h.reorgSubs {
+		select {
+		case sub.ch <- evt:
+		default:
+		}
+	}
+}
+
+// maybeEmitFeeUpdate computes the current fee suggestion from the in-memory
+// window and pushes it to subscribers if it changed meaningfully or enough
+// epochs have passed since the last push.
+func (h *CfxGasStationHandler) maybeEmitFeeUpdate() {
+	h.subsMu.Lock()
+	hasSubs := len(h.feeSubs) > 0
+	h.subsMu.Unlock()
+	if !hasSubs {
+		return
+	}
+
+	suggestion, ok := h.currentSuggestion()
+	if !ok {
+		return
+	}
+
+	h.feeUpdateMu.Lock()
+	shouldEmit := h.lastEmittedFee == nil ||
+		(h.feeUpdateConfig.EveryEpochs > 0 && h.fromEpoch-h.lastEmittedEpoch >= h.feeUpdateConfig.EveryEpochs) ||
+		feeChangedBeyondEpsilon(h.lastEmittedFee, suggestion, h.feeUpdateConfig.EpsilonWei)
+	if shouldEmit {
+		h.lastEmittedFee = suggestion
+		h.lastEmittedEpoch = h.fromEpoch
+	}
+	h.feeUpdateMu.Unlock()
+
+	if !shouldEmit {
+		return
+	}
+
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	for _, sub := range h.feeSubs {
+		select {
+		case sub.ch <- suggestion:
+		default: // slow subscriber, drop rather than block epoch processing
+		}
+	}
+}
+
+// currentSuggestion mirrors Suggest's computation, but sourced entirely from
+// locally observed state (the window and the last processed block's base
+// fee) since the sync loop that drives subscription updates has no
+// sdk.ClientOperator of its own to query. It reports ok=false while the
+// window hasn't produced an estimate yet.
+func (h *CfxGasStationHandler) currentSuggestion() (*types.SuggestedGasFees, bool) {
+	baseFee, _ := h.lastBaseFee.Load().(*big.Int)
+	if baseFee == nil {
+		return nil, false
+	}
+
+	stats := h.window.Calculate(h.config.Percentiles[:])
+	if stats.AvgPercentiledPriorityFee == nil {
+		return nil, false
+	}
+	priorityFees := stats.AvgPercentiledPriorityFee
+
+	return &types.SuggestedGasFees{
+		Low: types.GasFeeEstimation{
+			SuggestedMaxPriorityFeePerGas: (*hexutil.Big)(priorityFees[0]),
+			SuggestedMaxFeePerGas:         (*hexutil.Big)(big.NewInt(0).Add(baseFee, priorityFees[0])),
+		},
+		Medium: types.GasFeeEstimation{
+			SuggestedMaxPriorityFeePerGas: (*hexutil.Big)(priorityFees[1]),
+			SuggestedMaxFeePerGas:         (*hexutil.Big)(big.NewInt(0).Add(baseFee, priorityFees[1])),
+		},
+		High: types.GasFeeEstimation{
+			SuggestedMaxPriorityFeePerGas: (*hexutil.Big)(priorityFees[2]),
+			SuggestedMaxFeePerGas:         (*hexutil.Big)(big.NewInt(0).Add(baseFee, priorityFees[2])),
+		},
+		EstimatedBaseFee:       (*hexutil.Big)(baseFee),
+		NetworkCongestion:      stats.NetworkCongestion,
+		LatestPriorityFeeRange: ToHexBigSlice(stats.LatestPriorityFeeRange),
+		PriorityFeeTrend:       stats.PriorityFeeTrend,
+		BaseFeeTrend:           stats.BaseFeeTrend,
+	}, true
+}
+
+// feeChangedBeyondEpsilon reports whether any of Low/Medium/High's suggested
+// priority fee moved by more than epsilonWei between prev and cur.
+func feeChangedBeyondEpsilon(prev, cur *types.SuggestedGasFees, epsilonWei int64) bool {
+	epsilon := big.NewInt(epsilonWei)
+	pairs := [][2]*hexutil.Big{
+		{prev.Low.SuggestedMaxPriorityFeePerGas, cur.Low.SuggestedMaxPriorityFeePerGas},
+		{prev.Medium.SuggestedMaxPriorityFeePerGas, cur.Medium.SuggestedMaxPriorityFeePerGas},
+		{prev.High.SuggestedMaxPriorityFeePerGas, cur.High.SuggestedMaxPriorityFeePerGas},
+	}
+
+	for _, pair := range pairs {
+		diff := big.NewInt(0).Sub(pair[1].ToInt(), pair[0].ToInt())
+		if diff.Abs(diff).Cmp(epsilon) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/openweb3/go-rpc-provider"
+)
+
+// cfxGasPriceSubscriptionAPI exposes Subscribe as the "gasPrice" topic for
+// cfx_subscribe/eth_subscribe, following go-rpc-provider's pubsub convention:
+// an exported method that returns (*rpc.Subscription, error) and pushes
+// updates via the notifier bound to ctx.
+type cfxGasPriceSubscriptionAPI struct {
+	gasStation *CfxGasStationHandler
+}
+
+// GasPrice streams gas fee suggestion and reorg updates to a
+// cfx_subscribe("gasPrice", ...)/eth_subscribe("gasPrice", ...) caller,
+// proxying CfxGasStationHandler.Subscribe's channels into notifier pushes.
+func (api *cfxGasPriceSubscriptionAPI) GasPrice(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	feeCh, reorgCh, err := api.gasStation.Subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		for {
+			select {
+			case fee, ok := <-feeCh:
+				if !ok {
+					return
+				}
+				notifier.Notify(sub.ID, fee)
+			case evt, ok := <-reorgCh:
+				if !ok {
+					return
+				}
+				notifier.Notify(sub.ID, evt)
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+// SubscribeAPIs returns the rpc.API descriptors the node's RPC server should
+// append to its own API list when the gas station is enabled, registering
+// GasPrice as the "gasPrice" cfx_subscribe/eth_subscribe topic.
+func (h *CfxGasStationHandler) SubscribeAPIs() []rpc.API {
+	svc := &cfxGasPriceSubscriptionAPI{gasStation: h}
+
+	return []rpc.API{
+		{Namespace: "cfx", Version: "1.0", Service: svc, Public: true},
+		{Namespace: "eth", Version: "1.0", Service: svc, Public: true},
+	}
+}
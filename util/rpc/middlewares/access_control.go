@@ -2,24 +2,66 @@ package middlewares
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"math/big"
 
+	"github.com/Conflux-Chain/confura/rpc/handler"
 	"github.com/Conflux-Chain/confura/util/rate"
 	"github.com/Conflux-Chain/confura/util/rpc/handlers"
 	"github.com/Conflux-Chain/go-conflux-util/viper"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/openweb3/go-rpc-provider"
+	"github.com/sirupsen/logrus"
 )
 
 var (
-	errAccessForbidden = errors.New("access forbidden")
+	errAccessForbidden   = errors.New("access forbidden")
+	errTxFeeTooLow       = errors.New("tip too low for non-VIP access")
+	errTxFeeTierReserved = errors.New("tip reserved for SVIP access")
 )
 
+// Caller tiers recognized by the tx fee ACL, ordered from least to most
+// privileged.
+const (
+	tierPublic = "public"
+	tierVip    = "vip"
+	tierSvip   = "svip"
+)
+
+// ctxKeyGasStation is the context key the RPC server uses to share the
+// request's CfxGasStationHandler so middlewares can compare a submitted
+// transaction's fee against the live base fee/window without another round
+// trip to a fullnode.
+type ctxKeyGasStation struct{}
+
+// feeStatsSource is the subset of *handler.CfxGasStationHandler the tx fee
+// ACL depends on, narrowed to an interface so it can be exercised in tests
+// without a live gas station handler.
+type feeStatsSource interface {
+	CurrentFeeStats() (baseFee *big.Int, lowPriorityFee *big.Int, err error)
+}
+
+// ContextWithGasStation returns a context carrying gasStation for later
+// retrieval by the tx fee ACL.
+func ContextWithGasStation(ctx context.Context, gasStation *handler.CfxGasStationHandler) context.Context {
+	return context.WithValue(ctx, ctxKeyGasStation{}, gasStation)
+}
+
 type accessControlConfig struct {
 	// access control list of RPC methods for VIP only
 	VipOnlyAcl []string
+	// TxFeeAcl restricts methods carrying a raw signed transaction (e.g.
+	// cfx_sendRawTransaction/eth_sendRawTransaction) based on the tx's
+	// effective priority fee.
+	TxFeeAcl []txFeeAclRule
 }
 
-func MustNewVipOnlyAccessControlMiddlewareFromViper() rpc.HandleCallMsgMiddleware {
+// MustNewVipOnlyAccessControlMiddlewareFromViper builds the VIP-only and tx
+// fee access control middleware. gasStation may be nil (e.g. gas station
+// disabled), in which case the tx fee ACL is a no-op: checkTxFeeAcl always
+// sees a nil handler and lets the call through.
+func MustNewVipOnlyAccessControlMiddlewareFromViper(gasStation *handler.CfxGasStationHandler) rpc.HandleCallMsgMiddleware {
 	var conf accessControlConfig
 	viper.MustUnmarshalKey("accessControl", &conf)
 
@@ -29,26 +71,97 @@ func MustNewVipOnlyAccessControlMiddlewareFromViper() rpc.HandleCallMsgMiddlewar
 		acl[method] = struct{}{}
 	}
 
+	// tx fee ACL rules grouped by method
+	txFeeAcl := make(map[string][]txFeeAclRule)
+	for _, rule := range conf.TxFeeAcl {
+		txFeeAcl[rule.Method] = append(txFeeAcl[rule.Method], rule)
+	}
+
 	return func(next rpc.HandleCallMsgFunc) rpc.HandleCallMsgFunc {
 		return func(ctx context.Context, msg *rpc.JsonRpcMessage) *rpc.JsonRpcMessage {
-			if _, ok := acl[msg.Method]; !ok { // not in the restriction list?
-				return next(ctx, msg)
+			if gasStation != nil {
+				ctx = ContextWithGasStation(ctx, gasStation)
 			}
 
-			if _, ok := handlers.VipStatusFromContext(ctx); ok {
-				// access allowed for VIP user
+			tier := callerTier(ctx)
+
+			if rules, ok := txFeeAcl[msg.Method]; ok {
+				if resp := checkTxFeeAcl(ctx, msg, rules, tier); resp != nil {
+					return resp
+				}
+			}
+
+			if _, ok := acl[msg.Method]; !ok { // not in the restriction list?
 				return next(ctx, msg)
 			}
 
-			if registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry); ok {
-				svip, ok := registry.SVipStatusFromContext(ctx)
-				if ok && svip > 0 { // access allowed for SVIP user
-					return next(ctx, msg)
-				}
+			if tier != tierPublic { // access allowed for VIP/SVIP user
+				return next(ctx, msg)
 			}
 
 			// otherwise access forbidden
 			return msg.ErrorResponse(errAccessForbidden)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// callerTier resolves the caller's access tier from the same context values
+// the VIP-only ACL already relies on.
+func callerTier(ctx context.Context) string {
+	if registry, ok := ctx.Value(handlers.CtxKeyRateRegistry).(*rate.Registry); ok {
+		if svip, ok := registry.SVipStatusFromContext(ctx); ok && svip > 0 {
+			return tierSvip
+		}
+	}
+
+	if _, ok := handlers.VipStatusFromContext(ctx); ok {
+		return tierVip
+	}
+
+	return tierPublic
+}
+
+// checkTxFeeAcl evaluates the tx fee ACL rules configured for msg.Method
+// against the effective priority fee of the raw transaction it carries,
+// returning a forbidden response if a rule is violated, or nil to let the
+// call proceed.
+func checkTxFeeAcl(
+	ctx context.Context, msg *rpc.JsonRpcMessage, rules []txFeeAclRule, tier string,
+) *rpc.JsonRpcMessage {
+	gasStation, ok := ctx.Value(ctxKeyGasStation{}).(feeStatsSource)
+	if !ok {
+		return nil // no gas station handler shared for this request, skip the check
+	}
+
+	baseFee, _, err := gasStation.CurrentFeeStats()
+	if err != nil {
+		return nil // gas station not ready yet, don't block traffic on it
+	}
+
+	var params []hexutil.Bytes
+	if err := json.Unmarshal(msg.Params, &params); err != nil || len(params) == 0 {
+		return nil // malformed params, let the downstream handler report the error
+	}
+
+	tip, err := effectiveTip(msg.Method, params[0], baseFee)
+	if err != nil {
+		logrus.WithError(err).WithField("method", msg.Method).Debug(
+			"Tx fee ACL failed to decode raw transaction, skipping fee check")
+		return nil
+	}
+
+	for _, rule := range rules {
+		switch rule.Tier {
+		case tierVip:
+			if tier == tierPublic && tip.Cmp(rule.minTipWei()) < 0 {
+				return msg.ErrorResponse(errTxFeeTooLow)
+			}
+		case tierSvip:
+			if tier != tierSvip && tip.Cmp(rule.minTipWei()) >= 0 {
+				return msg.ErrorResponse(errTxFeeTierReserved)
+			}
+		}
+	}
+
+	return nil
+}
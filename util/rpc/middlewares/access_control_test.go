@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/openweb3/go-rpc-provider"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFeeStatsSource is a feeStatsSource test double standing in for a live
+// *handler.CfxGasStationHandler.
+type fakeFeeStatsSource struct {
+	baseFee *big.Int
+}
+
+func (f fakeFeeStatsSource) CurrentFeeStats() (*big.Int, *big.Int, error) {
+	return f.baseFee, big.NewInt(0), nil
+}
+
+// rawCfxTxWithGasPrice builds a minimal legacy cfx raw transaction carrying
+// the given gasPrice, in the [nonce, gasPrice, gasLimit] field order
+// cfxLegacyStyleEffectiveTip decodes.
+func rawCfxTxWithGasPrice(t *testing.T, gasPrice int64) hexutil.Bytes {
+	t.Helper()
+	return hexutil.Bytes(encodeCfxFields(t, 1, gasPrice, 21_000))
+}
+
+func callMsgParams(t *testing.T, rawTx hexutil.Bytes) json.RawMessage {
+	t.Helper()
+	params, err := json.Marshal([]hexutil.Bytes{rawTx})
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	return params
+}
+
+func TestCheckTxFeeAclVipTier(t *testing.T) {
+	const baseFeeWei = 1_000_000_000 // 1 Gwei
+	rules := []txFeeAclRule{
+		{Method: "cfx_sendRawTransaction", MinTipGwei: 2, Tier: tierVip},
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKeyGasStation{},
+		fakeFeeStatsSource{baseFee: big.NewInt(baseFeeWei)})
+	msg := &rpc.JsonRpcMessage{Method: "cfx_sendRawTransaction"}
+
+	// Public caller below the vip min tip (2 Gwei): rejected.
+	msg.Params = callMsgParams(t, rawCfxTxWithGasPrice(t, baseFeeWei+1_000_000_000)) // tip ~1 Gwei
+	resp := checkTxFeeAcl(ctx, msg, rules, tierPublic)
+	assertErrorResponse(t, resp, errTxFeeTooLow)
+
+	// Public caller at/above the vip min tip: allowed.
+	msg.Params = callMsgParams(t, rawCfxTxWithGasPrice(t, baseFeeWei+3_000_000_000)) // tip ~3 Gwei
+	assert.Nil(t, checkTxFeeAcl(ctx, msg, rules, tierPublic))
+
+	// VIP caller below the min tip: the vip rule doesn't apply to vip callers.
+	msg.Params = callMsgParams(t, rawCfxTxWithGasPrice(t, baseFeeWei+1_000_000_000))
+	assert.Nil(t, checkTxFeeAcl(ctx, msg, rules, tierVip))
+}
+
+func TestCheckTxFeeAclSvipTier(t *testing.T) {
+	const baseFeeWei = 1_000_000_000 // 1 Gwei
+	rules := []txFeeAclRule{
+		{Method: "cfx_sendRawTransaction", MinTipGwei: 5, Tier: tierSvip},
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKeyGasStation{},
+		fakeFeeStatsSource{baseFee: big.NewInt(baseFeeWei)})
+	msg := &rpc.JsonRpcMessage{Method: "cfx_sendRawTransaction"}
+
+	// VIP caller at/above the svip-reserved threshold: rejected, tier reserved.
+	msg.Params = callMsgParams(t, rawCfxTxWithGasPrice(t, baseFeeWei+6_000_000_000)) // tip ~6 Gwei
+	resp := checkTxFeeAcl(ctx, msg, rules, tierVip)
+	assertErrorResponse(t, resp, errTxFeeTierReserved)
+
+	// SVIP caller at the same tip: allowed.
+	assert.Nil(t, checkTxFeeAcl(ctx, msg, rules, tierSvip))
+
+	// VIP caller below the svip threshold: allowed, rule doesn't reserve it.
+	msg.Params = callMsgParams(t, rawCfxTxWithGasPrice(t, baseFeeWei+1_000_000_000))
+	assert.Nil(t, checkTxFeeAcl(ctx, msg, rules, tierVip))
+}
+
+func TestCheckTxFeeAclAggregatesRulesByMethod(t *testing.T) {
+	const baseFeeWei = 1_000_000_000 // 1 Gwei
+	rules := []txFeeAclRule{
+		{Method: "cfx_sendRawTransaction", MinTipGwei: 2, Tier: tierVip},
+		{Method: "cfx_sendRawTransaction", MinTipGwei: 5, Tier: tierSvip},
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKeyGasStation{},
+		fakeFeeStatsSource{baseFee: big.NewInt(baseFeeWei)})
+	msg := &rpc.JsonRpcMessage{Method: "cfx_sendRawTransaction"}
+
+	// Public caller between the two thresholds satisfies the vip floor and
+	// isn't anywhere near the svip-reserved band: allowed.
+	msg.Params = callMsgParams(t, rawCfxTxWithGasPrice(t, baseFeeWei+3_000_000_000)) // tip ~3 Gwei
+	assert.Nil(t, checkTxFeeAcl(ctx, msg, rules, tierPublic))
+
+	// Public caller below the vip floor: rejected by the vip rule, even
+	// though only one of the two rules actually fires.
+	msg.Params = callMsgParams(t, rawCfxTxWithGasPrice(t, baseFeeWei+1_000_000_000))
+	resp := checkTxFeeAcl(ctx, msg, rules, tierPublic)
+	assertErrorResponse(t, resp, errTxFeeTooLow)
+}
+
+// assertErrorResponse checks resp is a JSON-RPC error response whose message
+// matches want, without depending on the exact shape of rpc.JsonRpcMessage's
+// error field.
+func assertErrorResponse(t *testing.T, resp *rpc.JsonRpcMessage, want error) {
+	t.Helper()
+	if !assert.NotNil(t, resp) {
+		return
+	}
+
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	assert.Contains(t, string(raw), want.Error())
+}
@@ -0,0 +1,152 @@
+package middlewares
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// txFeeAclRule restricts a raw-tx-carrying RPC method based on the effective
+// priority fee (min(maxPriorityFeePerGas, maxFeePerGas - baseFee)) of the
+// transaction it submits, e.g. forbidding non-VIP users from broadcasting
+// txns whose tip is below the gas station's "Low" suggestion, or reserving
+// high-tip inclusion for SVIPs during congestion.
+type txFeeAclRule struct {
+	// Method is the JSON-RPC method this rule applies to, e.g.
+	// "cfx_sendRawTransaction" or "eth_sendRawTransaction".
+	Method string
+	// MinTipGwei is the effective priority fee threshold, in Gwei, that this
+	// rule enforces.
+	MinTipGwei float64
+	// Tier is the access tier this rule is evaluated against: "vip" requires
+	// at least vip access below the threshold, "svip" reserves at-or-above
+	// the threshold for svip access only.
+	Tier string
+}
+
+func (r txFeeAclRule) minTipWei() *big.Int {
+	wei := new(big.Float).Mul(big.NewFloat(r.MinTipGwei), big.NewFloat(1e9))
+	tip, _ := wei.Int(nil)
+	return tip
+}
+
+// effectiveTip extracts min(maxPriorityFeePerGas, maxFeePerGas - baseFee)
+// from a raw signed transaction as submitted to cfx_sendRawTransaction /
+// eth_sendRawTransaction.
+func effectiveTip(method string, rawTx hexutil.Bytes, baseFee *big.Int) (*big.Int, error) {
+	switch method {
+	case "eth_sendRawTransaction":
+		return ethEffectiveTip(rawTx, baseFee)
+	case "cfx_sendRawTransaction":
+		return cfxEffectiveTip(rawTx, baseFee)
+	default:
+		return nil, fmt.Errorf("unsupported method %q for tx fee ACL", method)
+	}
+}
+
+func ethEffectiveTip(rawTx hexutil.Bytes, baseFee *big.Int) (*big.Int, error) {
+	var txn gethtypes.Transaction
+	if err := txn.UnmarshalBinary(rawTx); err != nil {
+		return nil, fmt.Errorf("decode eth transaction: %w", err)
+	}
+
+	return minTip(txn.GasTipCap(), txn.GasFeeCap(), baseFee), nil
+}
+
+// cfx transaction type bytes, per Conflux's CIP-2718-style typed envelope.
+// A legacy transaction carries no type byte at all: it's RLP-list encoded
+// directly, so its first byte is always an RLP list header (>= 0xc0).
+const (
+	cfxTxTypeLegacy     = 0x00
+	cfxTxTypeAccessList = 0x01
+	cfxTxType1559       = 0x02
+)
+
+// cfxEffectiveTip recovers a Conflux core-space raw transaction's effective
+// priority fee, decoding only the fields its type actually carries: legacy
+// and access-list (type 1) transactions have a single gasPrice and no
+// separate tip, while CIP-1559 (type 2) transactions carry
+// maxPriorityFeePerGas/maxFeePerGas explicitly.
+func cfxEffectiveTip(rawTx hexutil.Bytes, baseFee *big.Int) (*big.Int, error) {
+	if len(rawTx) == 0 {
+		return nil, errors.New("empty raw transaction")
+	}
+
+	payload := []byte(rawTx)
+
+	txType := byte(cfxTxTypeLegacy)
+	if payload[0] < 0xc0 { // EIP-2718-style type byte, not an RLP list header
+		txType = payload[0]
+		payload = payload[1:]
+	}
+
+	switch txType {
+	case cfxTxTypeLegacy:
+		return cfxLegacyStyleEffectiveTip(payload, baseFee, 1) // nonce, gasPrice, ...
+	case cfxTxTypeAccessList:
+		return cfxLegacyStyleEffectiveTip(payload, baseFee, 2) // chainId, nonce, gasPrice, ...
+	case cfxTxType1559:
+		return cfx1559EffectiveTip(payload, baseFee)
+	default:
+		return nil, fmt.Errorf("unsupported cfx transaction type %#x for tx fee ACL", txType)
+	}
+}
+
+// cfxLegacyStyleEffectiveTip decodes a legacy or access-list transaction,
+// whose only fee field is a flat gasPrice at the given field index, and
+// derives the effective tip as gasPrice - baseFee (floored at 0, since
+// these transactions have no cap to compare it against).
+func cfxLegacyStyleEffectiveTip(payload []byte, baseFee *big.Int, gasPriceIdx int) (*big.Int, error) {
+	stream := rlp.NewStream(bytes.NewReader(payload), 0)
+	if _, err := stream.List(); err != nil {
+		return nil, fmt.Errorf("decode cfx transaction envelope: %w", err)
+	}
+
+	var gasPrice big.Int
+	for i := 0; i <= gasPriceIdx; i++ {
+		field := &big.Int{}
+		if i == gasPriceIdx {
+			field = &gasPrice
+		}
+		if err := stream.Decode(field); err != nil {
+			return nil, fmt.Errorf("decode cfx transaction field %d: %w", i, err)
+		}
+	}
+
+	tip := new(big.Int).Sub(&gasPrice, baseFee)
+	if tip.Sign() < 0 {
+		tip.SetInt64(0)
+	}
+	return tip, nil
+}
+
+// cfx1559EffectiveTip decodes a CIP-1559 transaction's
+// [chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, ...] field layout.
+func cfx1559EffectiveTip(payload []byte, baseFee *big.Int) (*big.Int, error) {
+	stream := rlp.NewStream(bytes.NewReader(payload), 0)
+	if _, err := stream.List(); err != nil {
+		return nil, fmt.Errorf("decode cfx 1559 transaction envelope: %w", err)
+	}
+
+	var chainID, nonce, maxPriorityFeePerGas, maxFeePerGas big.Int
+	for i, field := range []*big.Int{&chainID, &nonce, &maxPriorityFeePerGas, &maxFeePerGas} {
+		if err := stream.Decode(field); err != nil {
+			return nil, fmt.Errorf("decode cfx 1559 transaction field %d: %w", i, err)
+		}
+	}
+
+	return minTip(&maxPriorityFeePerGas, &maxFeePerGas, baseFee), nil
+}
+
+func minTip(maxPriorityFeePerGas, maxFeePerGas, baseFee *big.Int) *big.Int {
+	tip := new(big.Int).Sub(maxFeePerGas, baseFee)
+	if maxPriorityFeePerGas.Cmp(tip) < 0 {
+		tip.Set(maxPriorityFeePerGas)
+	}
+	return tip
+}
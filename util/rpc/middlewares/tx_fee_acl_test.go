@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeCfxFields RLP-encodes a flat list of integer fields, mirroring the
+// [field0, field1, ...] envelope cfxLegacyStyleEffectiveTip decodes. Using a
+// gasLimit distinct from gasPrice lets tests catch an off-by-one field index.
+func encodeCfxFields(t *testing.T, fields ...int64) []byte {
+	t.Helper()
+
+	vals := make([]*big.Int, len(fields))
+	for i, f := range fields {
+		vals[i] = big.NewInt(f)
+	}
+
+	raw, err := rlp.EncodeToBytes(vals)
+	if err != nil {
+		t.Fatalf("encode cfx fields: %v", err)
+	}
+	return raw
+}
+
+func TestCfxEffectiveTipLegacy(t *testing.T) {
+	const (
+		nonce     = 1
+		gasPrice  = 20_000_000_000 // 20 Gwei
+		gasLimit  = 21_000
+		baseFeeWu = 5_000_000_000 // 5 Gwei
+	)
+
+	rawTx := hexutil.Bytes(encodeCfxFields(t, nonce, gasPrice, gasLimit))
+
+	tip, err := effectiveTip("cfx_sendRawTransaction", rawTx, big.NewInt(baseFeeWu))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(gasPrice-baseFeeWu), tip)
+}
+
+func TestCfxEffectiveTipAccessList(t *testing.T) {
+	const (
+		chainID   = 1029
+		nonce     = 1
+		gasPrice  = 20_000_000_000 // 20 Gwei
+		gasLimit  = 21_000
+		baseFeeWu = 5_000_000_000 // 5 Gwei
+	)
+
+	payload := encodeCfxFields(t, chainID, nonce, gasPrice, gasLimit)
+	rawTx := hexutil.Bytes(append([]byte{cfxTxTypeAccessList}, payload...))
+
+	tip, err := effectiveTip("cfx_sendRawTransaction", rawTx, big.NewInt(baseFeeWu))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(gasPrice-baseFeeWu), tip)
+}
+
+func TestCfxEffectiveTipFloorsAtZero(t *testing.T) {
+	rawTx := hexutil.Bytes(encodeCfxFields(t, 1, 1_000_000_000, 21_000))
+
+	tip, err := effectiveTip("cfx_sendRawTransaction", rawTx, big.NewInt(5_000_000_000))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, tip.Sign())
+}